@@ -0,0 +1,125 @@
+/* Package cache is a small TTL cache for raw whois responses, keyed by
+ * (server, query), with an optional on-disk JSON backing file so
+ * repeated CLI invocations benefit from lookups a previous run already
+ * made.  Failed lookups (connection errors, empty responses) are
+ * cached too, under a shorter TTL, so a run of queries against an
+ * unresponsive server doesn't retry it on every single one.
+ */
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+/* NegativeTTLFraction is how much shorter a negative-result entry's
+ * TTL is than a normal one's, e.g. a 1h TTL caches connection
+ * failures for 5 minutes. */
+const NegativeTTLFraction = 12
+
+/* MinNegativeTTL is the floor under NegativeTTLFraction, so a very
+ * short -T doesn't effectively disable negative caching. */
+const MinNegativeTTL = 30 * time.Second
+
+type entry struct {
+	Response string    `json:"response"`
+	Negative bool      `json:"negative"`
+	Expires  time.Time `json:"expires"`
+}
+
+/* Cache is a (server, query) -> raw response cache.  The zero value is
+ * not usable; construct one with New or Load. */
+type Cache struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	negTTL  time.Duration
+	entries map[string]entry
+}
+
+func key(server, query string) string {
+	return server + "\x00" + query
+}
+
+/* New returns an empty, in-memory-only cache with the given TTL. */
+func New(ttl time.Duration) *Cache {
+	negTTL := ttl / NegativeTTLFraction
+	if negTTL < MinNegativeTTL {
+		negTTL = MinNegativeTTL
+	}
+	return &Cache{ttl: ttl, negTTL: negTTL, entries: map[string]entry{}}
+}
+
+/* Load returns a cache with the given TTL, pre-populated from path if
+ * it exists and parses; a missing or unreadable file just means an
+ * empty starting cache, same as New. Flush writes back to path. */
+func Load(path string, ttl time.Duration) *Cache {
+	c := New(ttl)
+	c.path = path
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+
+	var entries map[string]entry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return c
+	}
+	c.entries = entries
+
+	return c
+}
+
+/* Get returns the cached raw response for (server, query), if any
+ * unexpired entry exists.  negative reports whether the cached entry
+ * recorded a failed lookup, in which case response is empty. */
+func (c *Cache) Get(server, query string) (response string, negative bool, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key(server, query)]
+	if !ok || time.Now().After(e.Expires) {
+		return "", false, false
+	}
+	return e.Response, e.Negative, true
+}
+
+/* Set records response for (server, query).  An empty response is
+ * treated as a negative (failed) result and cached under the
+ * shorter negative TTL. */
+func (c *Cache) Set(server, query, response string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	negative := len(response) < 1
+	ttl := c.ttl
+	if negative {
+		ttl = c.negTTL
+	}
+
+	c.entries[key(server, query)] = entry{
+		Response: response,
+		Negative: negative,
+		Expires:  time.Now().Add(ttl),
+	}
+}
+
+/* Flush writes the cache out to its backing file, if it was created
+ * with Load (i.e. -C was given); it is a no-op otherwise. */
+func (c *Cache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.path) < 1 {
+		return nil
+	}
+
+	b, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, b, 0644)
+}
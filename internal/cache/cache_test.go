@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetGetRoundTrip(t *testing.T) {
+	c := New(time.Hour)
+
+	c.Set("whois.iana.org", "example.com", "some response")
+
+	response, negative, found := c.Get("whois.iana.org", "example.com")
+	if !found {
+		t.Fatal("expected a cache hit")
+	}
+	if negative {
+		t.Error("expected a positive entry, got negative")
+	}
+	if response != "some response" {
+		t.Errorf("got response %q, want %q", response, "some response")
+	}
+}
+
+func TestGetMiss(t *testing.T) {
+	c := New(time.Hour)
+
+	if _, _, found := c.Get("whois.iana.org", "example.com"); found {
+		t.Error("expected a miss on an empty cache")
+	}
+}
+
+func TestSetEmptyResponseIsNegative(t *testing.T) {
+	c := New(time.Hour)
+
+	c.Set("whois.iana.org", "example.com", "")
+
+	response, negative, found := c.Get("whois.iana.org", "example.com")
+	if !found {
+		t.Fatal("expected a cache hit for the negative entry")
+	}
+	if !negative {
+		t.Error("expected a negative entry for an empty response")
+	}
+	if response != "" {
+		t.Errorf("got non-empty response %q for a negative entry", response)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := New(time.Millisecond)
+
+	c.Set("whois.iana.org", "example.com", "some response")
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, _, found := c.Get("whois.iana.org", "example.com"); found {
+		t.Error("expected entry to have expired")
+	}
+}
+
+func TestNegativeTTLShorterThanPositive(t *testing.T) {
+	ttl := time.Hour
+	c := New(ttl)
+
+	if c.negTTL >= c.ttl {
+		t.Errorf("negTTL %s should be shorter than ttl %s", c.negTTL, c.ttl)
+	}
+	if c.negTTL != ttl/NegativeTTLFraction {
+		t.Errorf("negTTL = %s, want %s", c.negTTL, ttl/NegativeTTLFraction)
+	}
+}
+
+func TestNegativeTTLFloor(t *testing.T) {
+	c := New(time.Second)
+
+	if c.negTTL != MinNegativeTTL {
+		t.Errorf("negTTL = %s, want the floor %s", c.negTTL, MinNegativeTTL)
+	}
+}
+
+func TestLoadMissingFileIsEmpty(t *testing.T) {
+	c := Load(filepath.Join(t.TempDir(), "does-not-exist.json"), time.Hour)
+
+	if _, _, found := c.Get("whois.iana.org", "example.com"); found {
+		t.Error("expected an empty cache when loading a missing file")
+	}
+}
+
+func TestFlushAndLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	c := Load(path, time.Hour)
+	c.Set("whois.iana.org", "example.com", "some response")
+
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err)
+	}
+
+	reloaded := Load(path, time.Hour)
+	response, negative, found := reloaded.Get("whois.iana.org", "example.com")
+	if !found {
+		t.Fatal("expected the flushed entry to survive a reload")
+	}
+	if negative {
+		t.Error("expected a positive entry")
+	}
+	if response != "some response" {
+		t.Errorf("got response %q, want %q", response, "some response")
+	}
+}
+
+func TestFlushWithoutPathIsNoop(t *testing.T) {
+	c := New(time.Hour)
+
+	if err := c.Flush(); err != nil {
+		t.Errorf("Flush on an in-memory-only cache should be a no-op, got %s", err)
+	}
+}
@@ -0,0 +1,61 @@
+/* Package format turns the []map[string]interface{} that lookupWhois
+ * assembles -- one map per query, each holding one entry per whois hop
+ * plus "query" and "chain" -- into the bytes jswhois actually prints,
+ * so -o can pick among several on-the-wire shapes without
+ * lookupWhois caring which one it got.
+ */
+package format
+
+import (
+	"encoding/json"
+)
+
+/* Encoder turns a batch of lookup results (the same shape
+ * json.Marshal(allOutput) always got handed) into output bytes. */
+type Encoder interface {
+	Encode(records []map[string]interface{}) ([]byte, error)
+}
+
+/* DefaultFormat is what jswhois has always emitted. */
+const DefaultFormat = "json"
+
+var encoders = map[string]Encoder{
+	"json":  jsonEncoder{},
+	"text":  textEncoder{},
+	"yaml":  yamlEncoder{},
+	"jcard": jcardEncoder{},
+}
+
+/* Get returns the Encoder registered under name, if any. */
+func Get(name string) (Encoder, bool) {
+	e, found := encoders[name]
+	return e, found
+}
+
+/* Names lists the formats -o accepts, for usage text. */
+func Names() []string {
+	return []string{"json", "text", "yaml", "jcard"}
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(records []map[string]interface{}) ([]byte, error) {
+	return json.Marshal(records)
+}
+
+/* normalize round-trips records through JSON so every other encoder
+ * only ever has to deal with plain map[string]interface{},
+ * []interface{}, and scalars -- not askWhois's internal SubObject (a
+ * named map type) or []SubObject, which a type switch wouldn't
+ * otherwise match. */
+func normalize(records []map[string]interface{}) ([]map[string]interface{}, error) {
+	b, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
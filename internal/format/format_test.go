@@ -0,0 +1,168 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetKnownFormats(t *testing.T) {
+	for _, name := range Names() {
+		if _, found := Get(name); !found {
+			t.Errorf("Get(%q) not found, but it's listed in Names()", name)
+		}
+	}
+}
+
+func TestGetUnknownFormat(t *testing.T) {
+	if _, found := Get("xml"); found {
+		t.Error("expected no encoder registered for \"xml\"")
+	}
+}
+
+func TestYAMLEmptyMapAndSlice(t *testing.T) {
+	enc := yamlEncoder{}
+	records := []map[string]interface{}{
+		{"query": "example.com", "empty_map": map[string]interface{}{}, "empty_list": []interface{}{}},
+	}
+
+	out, err := enc.Encode(records)
+	if err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	got := string(out)
+	if !strings.Contains(got, "empty_map: {}\n") {
+		t.Errorf("expected an inline empty map, got:\n%s", got)
+	}
+	if !strings.Contains(got, "empty_list: []\n") {
+		t.Errorf("expected an inline empty list, got:\n%s", got)
+	}
+}
+
+func TestYAMLSpecialCharacterQuoting(t *testing.T) {
+	cases := map[string]bool{
+		"plain":            false,
+		"has: colon":       true,
+		"":                 true,
+		" leading space":   true,
+		"trailing space ":  true,
+		"true":             true,
+		"null":             true,
+		"normal sentence.": false,
+	}
+
+	for in, wantQuoted := range cases {
+		got := yamlScalar(in)
+		isQuoted := strings.HasPrefix(got, `"`)
+		if isQuoted != wantQuoted {
+			t.Errorf("yamlScalar(%q) = %q, quoted=%v, want quoted=%v", in, got, isQuoted, wantQuoted)
+		}
+	}
+}
+
+func TestYAMLNestedStructure(t *testing.T) {
+	enc := yamlEncoder{}
+	records := []map[string]interface{}{
+		{
+			"query": "example.com",
+			"nameservers": []interface{}{
+				"ns1.example.com",
+				"ns2.example.com",
+			},
+		},
+	}
+
+	out, err := enc.Encode(records)
+	if err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	got := string(out)
+	for _, want := range []string{"---\n", "nameservers:\n", "- ns1.example.com\n", "- ns2.example.com\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestJCardContactConversion(t *testing.T) {
+	enc := jcardEncoder{}
+	records := []map[string]interface{}{
+		{
+			"query": "example.com",
+			"registrant": map[string]interface{}{
+				"name":  "Jane Doe",
+				"email": "jane@example.com",
+			},
+		},
+	}
+
+	out, err := enc.Encode(records)
+	if err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	got := string(out)
+	for _, want := range []string{`"vcard"`, `"version"`, `"4.0"`, `"fn"`, `"Jane Doe"`, `"email"`, `"jane@example.com"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected jCard output to contain %s, got: %s", want, got)
+		}
+	}
+}
+
+func TestJCardUnknownFieldBecomesExtension(t *testing.T) {
+	contact := map[string]interface{}{"Some Odd Field": "value"}
+
+	card := contactToJCard(contact)
+	props, ok := card[1].([]interface{})
+	if !ok {
+		t.Fatalf("expected card[1] to be a property array, got %T", card[1])
+	}
+
+	found := false
+	for _, p := range props {
+		prop, ok := p.([]interface{})
+		if !ok || len(prop) < 1 {
+			continue
+		}
+		if prop[0] == "x-some-odd-field" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected an unrecognized field to become an x- extension property, got %v", props)
+	}
+}
+
+func TestJCardTextJoinsStringSlice(t *testing.T) {
+	got := jcardText([]interface{}{"123 Main St", "Springfield"})
+	want := "123 Main St, Springfield"
+	if got != want {
+		t.Errorf("jcardText = %q, want %q", got, want)
+	}
+}
+
+func TestTextEncoderFlattensNested(t *testing.T) {
+	enc := textEncoder{}
+	records := []map[string]interface{}{
+		{
+			"query": "example.com",
+			"whois.example.com": map[string]interface{}{
+				"registrant": map[string]interface{}{"name": "Jane Doe"},
+			},
+		},
+	}
+
+	out, err := enc.Encode(records)
+	if err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	got := string(out)
+	if !strings.Contains(got, "query: example.com\n") {
+		t.Errorf("expected a query: line, got:\n%s", got)
+	}
+	if !strings.Contains(got, "whois.example.com.registrant.name: Jane Doe\n") {
+		t.Errorf("expected a dotted flattened path, got:\n%s", got)
+	}
+}
@@ -0,0 +1,120 @@
+package format
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+/* contactKeyRE matches the subobject keys askWhois produces for
+ * contact-like blocks (registrant/admin/tech/contact, and RDAP's own
+ * "registrant"/"administrative"/"technical" roles via schema.Contact
+ * field names), so jcard knows which nested maps to turn into RFC
+ * 7095 jCard arrays rather than leaving as plain objects. */
+var contactKeyRE = regexp.MustCompile(`(?i)^(registrant|admin(istrative)?|tech(nical)?|contact|registrar)$`)
+
+/* jcardEncoder emits the same structure as json, except that any
+ * contact-like subobject is replaced by its RFC 7095 jCard array
+ * (["vcard", [[prop, {}, type, value], ...]]) so downstream tooling
+ * can consume contacts as standard vCard-in-JSON without having to
+ * know jswhois's own field names. */
+type jcardEncoder struct{}
+
+func (jcardEncoder) Encode(records []map[string]interface{}) ([]byte, error) {
+	records, err := normalize(records)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]map[string]interface{}, len(records))
+	for i, rec := range records {
+		out[i] = jcardWalk(rec).(map[string]interface{})
+	}
+
+	return json.Marshal(out)
+}
+
+/* jcardWalk recurses through v, replacing every contact-like
+ * map[string]interface{} (matched by key, in the parent) with its
+ * jCard form. */
+func jcardWalk(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, sub := range val {
+			if m, ok := sub.(map[string]interface{}); ok && contactKeyRE.MatchString(k) {
+				out[k] = contactToJCard(m)
+				continue
+			}
+			out[k] = jcardWalk(sub)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = jcardWalk(item)
+		}
+		return out
+	default:
+		return val
+	}
+}
+
+/* jcardProperties maps the field names askWhois/schema.Contact use
+ * onto their RFC 6350 vCard property names; anything else becomes an
+ * "x-<key>" extension property so no data is silently dropped. */
+var jcardProperties = map[string]string{
+	"name":         "fn",
+	"organisation": "org",
+	"organization": "org",
+	"address":      "adr",
+	"phone":        "tel",
+	"e-mail":       "email",
+	"email":        "email",
+	"fax-no":       "tel",
+}
+
+/* contactToJCard converts one contact-like subobject into a jCard
+ * array.  Fields are emitted in a stable order (version first, then
+ * sorted by vCard property name) so output is reproducible. */
+func contactToJCard(contact map[string]interface{}) []interface{} {
+	props := [][]interface{}{
+		{"version", map[string]interface{}{}, "text", "4.0"},
+	}
+
+	for _, k := range sortedKeys(contact) {
+		prop, known := jcardProperties[strings.ToLower(k)]
+		if !known {
+			prop = "x-" + strings.ToLower(strings.ReplaceAll(k, " ", "-"))
+		}
+		props = append(props, []interface{}{prop, map[string]interface{}{}, "text", jcardText(contact[k])})
+	}
+
+	array := make([]interface{}, len(props))
+	for i, p := range props {
+		array[i] = p
+	}
+
+	return []interface{}{"vcard", array}
+}
+
+/* jcardText renders a field value as the single text jCard expects;
+ * []interface{} values (e.g. a multi-line address) are joined, since
+ * jCard's structured "adr" type is more detail than our free-form
+ * WHOIS address lines warrant here. */
+func jcardText(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []interface{}:
+		parts := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,79 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/* textEncoder emits flat "key: value" lines grouped by whois hop,
+ * similar to a raw whois response but deduplicated and normalized
+ * through the same data cleanupData/expand already produced -- so a
+ * nameserver entry like {"ns1.example.com": ["1.2.3.4"]} prints as
+ * "nserver.ns1.example.com: 1.2.3.4" rather than being lost. */
+type textEncoder struct{}
+
+func (textEncoder) Encode(records []map[string]interface{}) ([]byte, error) {
+	records, err := normalize(records)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	for i, rec := range records {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if q, ok := rec["query"]; ok {
+			fmt.Fprintf(&buf, "query: %v\n", q)
+		}
+		for _, key := range sortedKeys(rec) {
+			if key == "query" {
+				continue
+			}
+			writeFlat(&buf, key, rec[key])
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/* writeFlat recursively prints v under the dotted path prefix,
+ * handling every shape askWhois/cleanupData can produce: plain
+ * strings, []string (repeated values), and nested
+ * map[string]interface{} (subobjects and the nameserver -> addresses
+ * map). */
+func writeFlat(buf *bytes.Buffer, prefix string, v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(val) {
+			writeFlat(buf, prefix+"."+k, val[k])
+		}
+	case []interface{}:
+		for _, item := range val {
+			switch item.(type) {
+			case map[string]interface{}:
+				writeFlat(buf, prefix, item)
+			default:
+				fmt.Fprintf(buf, "%s: %v\n", prefix, item)
+			}
+		}
+	case []string:
+		fmt.Fprintf(buf, "%s: %s\n", prefix, strings.Join(val, ", "))
+	case nil:
+		/* nothing to print */
+	default:
+		fmt.Fprintf(buf, "%s: %v\n", prefix, val)
+	}
+}
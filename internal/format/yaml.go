@@ -0,0 +1,103 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/* yamlEncoder hand-rolls a minimal YAML block-style dump of the same
+ * structure the other encoders see (maps, slices, strings, numbers,
+ * bools, nil) -- there is no YAML library available to this tree, and
+ * the subset of YAML our data needs (nested mappings and sequences of
+ * scalars) doesn't call for one. */
+type yamlEncoder struct{}
+
+func (yamlEncoder) Encode(records []map[string]interface{}) ([]byte, error) {
+	records, err := normalize(records)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range records {
+		buf.WriteString("---\n")
+		writeYAMLValue(&buf, 0, rec)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeYAMLValue(buf *bytes.Buffer, indent int, v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(val) {
+			writeYAMLEntry(buf, indent, yamlScalar(k), val[k])
+		}
+	case []interface{}:
+		for _, item := range val {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				fmt.Fprintf(buf, "%s-\n", strings.Repeat("  ", indent))
+				writeYAMLValue(buf, indent+1, item)
+			default:
+				fmt.Fprintf(buf, "%s- %s\n", strings.Repeat("  ", indent), yamlScalarValue(item))
+			}
+		}
+	default:
+		fmt.Fprintf(buf, "%s%s\n", strings.Repeat("  ", indent), yamlScalarValue(val))
+	}
+}
+
+func writeYAMLEntry(buf *bytes.Buffer, indent int, key string, v interface{}) {
+	pad := strings.Repeat("  ", indent)
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			fmt.Fprintf(buf, "%s%s: {}\n", pad, key)
+			return
+		}
+		fmt.Fprintf(buf, "%s%s:\n", pad, key)
+		writeYAMLValue(buf, indent+1, val)
+	case []interface{}:
+		if len(val) == 0 {
+			fmt.Fprintf(buf, "%s%s: []\n", pad, key)
+			return
+		}
+		fmt.Fprintf(buf, "%s%s:\n", pad, key)
+		writeYAMLValue(buf, indent, val)
+	default:
+		fmt.Fprintf(buf, "%s%s: %s\n", pad, key, yamlScalarValue(val))
+	}
+}
+
+func yamlScalarValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return yamlScalar(val)
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return yamlScalar(fmt.Sprintf("%v", val))
+	}
+}
+
+/* yamlScalar quotes a string scalar if it would otherwise be
+ * ambiguous (empty, leading/trailing space, or containing a character
+ * that's part of YAML's own syntax). */
+func yamlScalar(s string) string {
+	if len(s) == 0 {
+		return `""`
+	}
+	needsQuote := strings.ContainsAny(s, ":#{}[]&*!|>'\"%@`,") ||
+		strings.TrimSpace(s) != s ||
+		s == "null" || s == "~" || s == "true" || s == "false"
+	if !needsQuote {
+		return s
+	}
+	return strconv.Quote(s)
+}
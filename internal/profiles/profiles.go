@@ -0,0 +1,145 @@
+/* Package profiles holds the per-whois-server quirks askWhois needs
+ * to parse a response: which of the handful of known formats a
+ * server uses, whether it groups entries into subobjects, and so
+ * on.  These used to live as several parallel top-level maps in
+ * jswhois.go; they're collected here as a single ServerProfile so
+ * third parties can add or override a server's profile (and, via
+ * RegisterFormat, the regex a new format name matches) without
+ * patching the binary -- point -profiles at a JSON file or directory
+ * to load additions at runtime.
+ */
+package profiles
+
+import (
+	"encoding/json"
+	_ "embed"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+//go:embed defaults.json
+var defaultsJSON []byte
+
+/* ServerProfile captures everything askWhois looks up per server. */
+type ServerProfile struct {
+	/* Format names one of the FORMAT_PATTERNS entries; the zero
+	 * value means "use the default (twoColumnsStrict)". */
+	Format string `json:"format,omitempty"`
+
+	/* CreateObject groups key/value pairs separated by blank
+	 * lines into a subobject. */
+	CreateObject bool `json:"createObject,omitempty"`
+
+	/* TwoColumn forces single-column continuation lines to
+	 * attach to the previous key rather than becoming comments. */
+	TwoColumn bool `json:"twoColumn,omitempty"`
+
+	/* IgnoreEnd means this server's output continues past the
+	 * usual 'end of record' markers. */
+	IgnoreEnd bool `json:"ignoreEnd,omitempty"`
+
+	/* StripStrings are prefixes/suffixes this server decorates
+	 * its keys or object markers with (e.g. "**", "[", "]"). */
+	StripStrings []string `json:"stripStrings,omitempty"`
+}
+
+var (
+	mu       sync.RWMutex
+	profiles = map[string]ServerProfile{}
+	formats  = map[string]*regexp.Regexp{}
+)
+
+func init() {
+	var defaults map[string]ServerProfile
+	if err := json.Unmarshal(defaultsJSON, &defaults); err != nil {
+		panic("profiles: invalid embedded defaults.json: " + err.Error())
+	}
+	for server, p := range defaults {
+		profiles[server] = p
+	}
+}
+
+/* RegisterProfile adds or overrides the profile used for server.
+ * Third parties (and -profiles) use this to add support for a new
+ * ccTLD server, or to fix a brittle built-in regex, without a fork. */
+func RegisterProfile(server string, p ServerProfile) {
+	mu.Lock()
+	defer mu.Unlock()
+	profiles[server] = p
+}
+
+/* Lookup returns the profile for server, and whether one was
+ * registered at all (the zero value is a reasonable default either
+ * way: no special-casing). */
+func Lookup(server string) (ServerProfile, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, found := profiles[server]
+	return p, found
+}
+
+/* RegisterFormat adds a new named format pattern, so a ServerProfile
+ * can reference it via Format.  Servers using a registered format
+ * that askWhois's parser doesn't special-case fall back to its
+ * generic (strict two-column) handling. */
+func RegisterFormat(name string, re *regexp.Regexp) {
+	mu.Lock()
+	defer mu.Unlock()
+	formats[name] = re
+}
+
+/* FormatPattern returns a registered (non-built-in) format's regex,
+ * if any. */
+func FormatPattern(name string) (*regexp.Regexp, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	re, found := formats[name]
+	return re, found
+}
+
+/* Load merges the JSON-encoded map[string]ServerProfile found at
+ * path into the registry, overriding any defaults for servers it
+ * names.  If path is a directory, every *.json file in it is merged
+ * (in directory order). */
+func Load(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return loadFile(path)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if err := loadFile(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var loaded map[string]ServerProfile
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for server, p := range loaded {
+		profiles[server] = p
+	}
+	return nil
+}
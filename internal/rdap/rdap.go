@@ -0,0 +1,276 @@
+/* Package rdap is a minimal RFC 7480/9082 client: given a domain
+ * name, IP address, or ASN, it consults the IANA RDAP bootstrap
+ * registry to find the authoritative RDAP base URL and fetches the
+ * record as JSON.  It is used as an alternative to (or, in "auto"
+ * mode, a first attempt before) the port-43 WHOIS path.
+ */
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BootstrapDNS  = "https://data.iana.org/rdap/dns.json"
+	BootstrapIPv4 = "https://data.iana.org/rdap/ipv4.json"
+	BootstrapIPv6 = "https://data.iana.org/rdap/ipv6.json"
+	BootstrapASN  = "https://data.iana.org/rdap/asn.json"
+)
+
+/* ErrNotFound is returned when the RDAP server has no record for the
+ * query (HTTP 404), which is the usual "auto" fallback-to-WHOIS
+ * signal. */
+var ErrNotFound = errors.New("rdap: not found")
+
+var asnRE = regexp.MustCompile(`(?i)^AS\d+$`)
+
+type bootstrapFile struct {
+	Services [][]interface{} `json:"services"`
+}
+
+/* Client fetches RDAP bootstrap files and records over HTTPS,
+ * caching bootstrap files for its lifetime. */
+type Client struct {
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	bootstrap map[string]*bootstrapFile
+}
+
+/* NewClient returns a ready-to-use Client with a sane default
+ * timeout. */
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		bootstrap:  map[string]*bootstrapFile{},
+	}
+}
+
+func (c *Client) fetchBootstrap(ctx context.Context, url string) (*bootstrapFile, error) {
+	c.mu.Lock()
+	if b, found := c.bootstrap[url]; found {
+		c.mu.Unlock()
+		return b, nil
+	}
+	c.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rdap: bootstrap %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	var b bootstrapFile
+	if err := json.NewDecoder(resp.Body).Decode(&b); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.bootstrap[url] = &b
+	c.mu.Unlock()
+
+	return &b, nil
+}
+
+/* baseURLFor walks a bootstrap file's "services" entries (each a
+ * [keys, urls] pair) and returns the first URL whose keys satisfy
+ * matches. */
+func baseURLFor(entries [][]interface{}, matches func(key string) bool) string {
+	for _, entry := range entries {
+		if len(entry) < 2 {
+			continue
+		}
+		keys, _ := entry[0].([]interface{})
+		urls, _ := entry[1].([]interface{})
+		if len(urls) < 1 {
+			continue
+		}
+		for _, k := range keys {
+			ks, ok := k.(string)
+			if ok && matches(ks) {
+				if u, ok := urls[0].(string); ok {
+					return strings.TrimRight(u, "/")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+/* DomainBaseURL returns the authoritative RDAP base URL for a domain
+ * name, per the IANA DNS bootstrap registry. */
+func (c *Client) DomainBaseURL(ctx context.Context, name string) (string, error) {
+	b, err := c.fetchBootstrap(ctx, BootstrapDNS)
+	if err != nil {
+		return "", err
+	}
+
+	tld := name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		tld = name[i+1:]
+	}
+	tld = strings.ToLower(tld)
+
+	url := baseURLFor(b.Services, func(k string) bool { return strings.ToLower(k) == tld })
+	if len(url) < 1 {
+		return "", fmt.Errorf("rdap: no bootstrap entry for .%s", tld)
+	}
+	return url, nil
+}
+
+func (c *Client) ipBaseURL(ctx context.Context, bootstrapURL string, addr net.IP) (string, error) {
+	b, err := c.fetchBootstrap(ctx, bootstrapURL)
+	if err != nil {
+		return "", err
+	}
+
+	url := baseURLFor(b.Services, func(k string) bool {
+		_, cidr, err := net.ParseCIDR(k)
+		return err == nil && cidr.Contains(addr)
+	})
+	if len(url) < 1 {
+		return "", fmt.Errorf("rdap: no bootstrap entry for %s", addr)
+	}
+	return url, nil
+}
+
+/* IPBaseURL returns the authoritative RDAP base URL for an IPv4 or
+ * IPv6 address. */
+func (c *Client) IPBaseURL(ctx context.Context, addr string) (string, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", fmt.Errorf("rdap: not an IP address: %s", addr)
+	}
+	if ip.To4() != nil {
+		return c.ipBaseURL(ctx, BootstrapIPv4, ip)
+	}
+	return c.ipBaseURL(ctx, BootstrapIPv6, ip)
+}
+
+/* ASNBaseURL returns the authoritative RDAP base URL for an
+ * autonomous system number (accepted with or without the "AS"
+ * prefix). */
+func (c *Client) ASNBaseURL(ctx context.Context, asn string) (string, error) {
+	b, err := c.fetchBootstrap(ctx, BootstrapASN)
+	if err != nil {
+		return "", err
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(asn)), "AS"))
+	if err != nil {
+		return "", fmt.Errorf("rdap: not an ASN: %s", asn)
+	}
+
+	url := baseURLFor(b.Services, func(k string) bool {
+		parts := strings.SplitN(k, "-", 2)
+		lo, err1 := strconv.Atoi(parts[0])
+		hi, err2 := lo, error(nil)
+		if len(parts) > 1 {
+			hi, err2 = strconv.Atoi(parts[1])
+		}
+		return err1 == nil && err2 == nil && n >= lo && n <= hi
+	})
+	if len(url) < 1 {
+		return "", fmt.Errorf("rdap: no bootstrap entry for AS%d", n)
+	}
+	return url, nil
+}
+
+/* Fetch GETs an already-known RDAP URL directly, skipping bootstrap
+ * discovery -- e.g. to follow a "related" referral link found in a
+ * previous response. */
+func (c *Client) Fetch(ctx context.Context, url string) (map[string]interface{}, error) {
+	return c.fetch(ctx, url)
+}
+
+func (c *Client) fetch(ctx context.Context, url string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rdap: %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	var data map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+/* LookupDomain fetches the RDAP record for a domain name, resolving
+ * the authoritative server via the IANA DNS bootstrap registry.  It
+ * returns the parsed record and the base URL it was fetched from. */
+func (c *Client) LookupDomain(ctx context.Context, name string) (map[string]interface{}, string, error) {
+	base, err := c.DomainBaseURL(ctx, name)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := c.fetch(ctx, base+"/domain/"+name)
+	return data, base, err
+}
+
+/* LookupIP fetches the RDAP record for an IPv4 or IPv6 address. */
+func (c *Client) LookupIP(ctx context.Context, addr string) (map[string]interface{}, string, error) {
+	base, err := c.IPBaseURL(ctx, addr)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := c.fetch(ctx, base+"/ip/"+addr)
+	return data, base, err
+}
+
+/* LookupASN fetches the RDAP record for an autonomous system
+ * number. */
+func (c *Client) LookupASN(ctx context.Context, asn string) (map[string]interface{}, string, error) {
+	base, err := c.ASNBaseURL(ctx, asn)
+	if err != nil {
+		return nil, "", err
+	}
+	n := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(asn)), "AS")
+	data, err := c.fetch(ctx, base+"/autnum/"+n)
+	return data, base, err
+}
+
+/* Lookup dispatches to LookupIP, LookupASN, or LookupDomain based on
+ * the shape of query. */
+func (c *Client) Lookup(ctx context.Context, query string) (map[string]interface{}, string, error) {
+	if net.ParseIP(query) != nil {
+		return c.LookupIP(ctx, query)
+	}
+	if asnRE.MatchString(query) {
+		return c.LookupASN(ctx, query)
+	}
+	return c.LookupDomain(ctx, query)
+}
@@ -0,0 +1,119 @@
+package rdap
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBaseURLForDomain(t *testing.T) {
+	entries := [][]interface{}{
+		{[]interface{}{"com", "net"}, []interface{}{"https://rdap.verisign.com/"}},
+		{[]interface{}{"org"}, []interface{}{"https://rdap.publicinterestregistry.org"}},
+	}
+
+	got := baseURLFor(entries, func(k string) bool { return k == "com" })
+	if got != "https://rdap.verisign.com" {
+		t.Errorf("got %q, want trailing slash trimmed from the verisign URL", got)
+	}
+
+	if got := baseURLFor(entries, func(k string) bool { return k == "net" }); got != "https://rdap.verisign.com" {
+		t.Errorf("expected the same entry to match any of its keys, got %q", got)
+	}
+
+	if got := baseURLFor(entries, func(k string) bool { return k == "io" }); got != "" {
+		t.Errorf("expected no match for an absent key, got %q", got)
+	}
+}
+
+func TestBaseURLForIPCIDR(t *testing.T) {
+	entries := [][]interface{}{
+		{[]interface{}{"192.0.2.0/24"}, []interface{}{"https://rdap.example.net"}},
+	}
+
+	matches := func(k string) bool {
+		_, cidr, err := net.ParseCIDR(k)
+		return err == nil && cidr.Contains(net.ParseIP("192.0.2.42"))
+	}
+	if got := baseURLFor(entries, matches); got != "https://rdap.example.net" {
+		t.Errorf("got %q, want the CIDR entry covering the address", got)
+	}
+
+	outside := func(k string) bool {
+		_, cidr, err := net.ParseCIDR(k)
+		return err == nil && cidr.Contains(net.ParseIP("198.51.100.1"))
+	}
+	if got := baseURLFor(entries, outside); got != "" {
+		t.Errorf("expected no match for an address outside the CIDR, got %q", got)
+	}
+}
+
+func TestFetchBootstrapCachesResult(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"services":[[["com"],["https://rdap.example.com"]]]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+
+	for i := 0; i < 3; i++ {
+		b, err := c.fetchBootstrap(context.Background(), srv.URL)
+		if err != nil {
+			t.Fatalf("fetchBootstrap: %s", err)
+		}
+		if len(b.Services) != 1 {
+			t.Fatalf("got %d services, want 1", len(b.Services))
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("fetchBootstrap hit the server %d times, want exactly 1 (later calls should be cached)", calls)
+	}
+}
+
+func TestFetchBootstrapBadStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	if _, err := c.fetchBootstrap(context.Background(), srv.URL); err == nil {
+		t.Error("expected an error for a non-200 bootstrap response")
+	}
+}
+
+func TestFetchNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	if _, err := c.Fetch(context.Background(), srv.URL); err != ErrNotFound {
+		t.Errorf("got err %v, want ErrNotFound", err)
+	}
+}
+
+func TestFetchSuccess(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept"); got != "application/rdap+json" {
+			t.Errorf("Accept header = %q, want application/rdap+json", got)
+		}
+		w.Write([]byte(`{"handle":"EXAMPLE"}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	data, err := c.Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Fetch: %s", err)
+	}
+	if data["handle"] != "EXAMPLE" {
+		t.Errorf("got %v, want handle EXAMPLE", data)
+	}
+}
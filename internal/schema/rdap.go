@@ -0,0 +1,143 @@
+package schema
+
+import (
+	"strings"
+)
+
+/* vcardValue pulls the first text value for vcard property name out
+ * of an RDAP vcardArray (["vcard", [[name, params, type, value], ...]]). */
+func vcardValue(vcardArray interface{}, name string) string {
+	arr, ok := vcardArray.([]interface{})
+	if !ok || len(arr) < 2 {
+		return ""
+	}
+	props, ok := arr[1].([]interface{})
+	if !ok {
+		return ""
+	}
+	for _, p := range props {
+		prop, ok := p.([]interface{})
+		if !ok || len(prop) < 4 {
+			continue
+		}
+		pname, _ := prop[0].(string)
+		if !strings.EqualFold(pname, name) {
+			continue
+		}
+		if v, ok := prop[3].(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func contactFromEntity(entity map[string]interface{}) *Contact {
+	c := &Contact{}
+	if vcard, found := entity["vcardArray"]; found {
+		c.Name = vcardValue(vcard, "fn")
+		c.Organization = vcardValue(vcard, "org")
+		c.Email = vcardValue(vcard, "email")
+		c.Phone = vcardValue(vcard, "tel")
+	}
+	return c
+}
+
+func hasRole(entity map[string]interface{}, role string) bool {
+	roles, ok := entity["roles"].([]interface{})
+	if !ok {
+		return false
+	}
+	for _, r := range roles {
+		if s, ok := r.(string); ok && strings.EqualFold(s, role) {
+			return true
+		}
+	}
+	return false
+}
+
+/* NormalizeRDAP maps an RDAP domain/ip/autnum response (as decoded
+ * from JSON into a generic map[string]interface{}) into the same
+ * canonical WhoisRecord that Normalize produces from a parsed WHOIS
+ * response, so callers get one consistent shape regardless of which
+ * transport answered the query. */
+func NormalizeRDAP(data map[string]interface{}) *WhoisRecord {
+	r := &WhoisRecord{}
+
+	if name, ok := data["ldhName"].(string); ok {
+		r.DomainName = name
+	} else if handle, ok := data["handle"].(string); ok {
+		r.DomainName = handle
+	}
+
+	if nameservers, ok := data["nameservers"].([]interface{}); ok {
+		for _, ns := range nameservers {
+			if m, ok := ns.(map[string]interface{}); ok {
+				if name, ok := m["ldhName"].(string); ok {
+					r.NameServers = append(r.NameServers, name)
+				}
+			}
+		}
+	}
+
+	if statuses, ok := data["status"].([]interface{}); ok {
+		for _, s := range statuses {
+			if str, ok := s.(string); ok {
+				r.Status = append(r.Status, str)
+			}
+		}
+	}
+
+	if events, ok := data["events"].([]interface{}); ok {
+		for _, e := range events {
+			m, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			action, _ := m["eventAction"].(string)
+			dateStr, _ := m["eventDate"].(string)
+			t, parsed := ParseDate(dateStr)
+			if !parsed {
+				continue
+			}
+			switch strings.ToLower(action) {
+			case "registration":
+				r.CreatedDate = &t
+			case "expiration":
+				r.ExpiresDate = &t
+			case "last changed", "last update of rdap database":
+				r.UpdatedDate = &t
+			}
+		}
+	}
+
+	if entities, ok := data["entities"].([]interface{}); ok {
+		for _, e := range entities {
+			entity, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			contact := contactFromEntity(entity)
+			switch {
+			case hasRole(entity, "registrant"):
+				r.Registrant = contact
+			case hasRole(entity, "administrative"):
+				r.Admin = contact
+			case hasRole(entity, "technical"):
+				r.Tech = contact
+			case hasRole(entity, "registrar"):
+				r.Registrar = contact.Name
+			}
+		}
+	}
+
+	raw := []string{}
+	if b, ok := data["port43"].(string); ok {
+		raw = append(raw, b)
+	}
+	r.Emails = ExtractEmails(strings.Join(raw, "\n"))
+	if r.Registrant != nil && len(r.Registrant.Email) > 0 {
+		r.Emails = append(r.Emails, r.Registrant.Email)
+	}
+
+	return r
+}
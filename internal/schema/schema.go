@@ -0,0 +1,279 @@
+/* Package schema normalizes the free-form key/value tree produced by
+ * the WHOIS parser (or, later, an RDAP response) into a typed
+ * WhoisRecord, so callers get a consistent shape regardless of which
+ * whois server answered the query.
+ *
+ * This is a best-effort normalization: the many observed key
+ * spellings are mapped onto canonical fields via regex, dates are
+ * tried against a list of known formats, and country codes are
+ * expanded to names via an embedded ISO-3166 table.
+ */
+package schema
+
+import (
+	"bufio"
+	_ "embed"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//go:embed countries.csv
+var countriesCSV string
+
+var countryNames = map[string]string{}
+var countryAlpha3 = map[string]string{}
+
+func init() {
+	scanner := bufio.NewScanner(strings.NewReader(countriesCSV))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ",", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		alpha2, alpha3, name := fields[0], fields[1], fields[2]
+		countryNames[alpha2] = name
+		countryAlpha3[alpha3] = alpha2
+	}
+}
+
+/* Contact mirrors the registrant/admin/tech blocks commonly found in
+ * WHOIS and RDAP output. */
+type Contact struct {
+	Name         string `json:"name,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	Fax          string `json:"fax,omitempty"`
+	Street       string `json:"street,omitempty"`
+	City         string `json:"city,omitempty"`
+	State        string `json:"state,omitempty"`
+	PostalCode   string `json:"postalCode,omitempty"`
+	Country      string `json:"country,omitempty"`
+}
+
+/* WhoisRecord is the canonical, typed shape that the many differing
+ * whois (and RDAP) responses get normalized into. */
+type WhoisRecord struct {
+	DomainName  string     `json:"domainName,omitempty"`
+	Registrar   string     `json:"registrar,omitempty"`
+	CreatedDate *time.Time `json:"createdDate,omitempty"`
+	UpdatedDate *time.Time `json:"updatedDate,omitempty"`
+	ExpiresDate *time.Time `json:"expiresDate,omitempty"`
+	NameServers []string   `json:"nameServers,omitempty"`
+	Emails      []string   `json:"emails,omitempty"`
+	Status      []string   `json:"status,omitempty"`
+	Registrant  *Contact   `json:"registrant,omitempty"`
+	Admin       *Contact   `json:"admin,omitempty"`
+	Tech        *Contact   `json:"tech,omitempty"`
+	CountryCode string     `json:"countryCode,omitempty"`
+	CountryName string     `json:"countryName,omitempty"`
+}
+
+/* fieldPatterns maps the many observed key spellings (across the
+ * whois servers jswhois talks to) to the canonical field they feed.
+ * Matching is case-insensitive against the trimmed key. */
+var fieldPatterns = map[string]*regexp.Regexp{
+	"domainName":  regexp.MustCompile(`(?i)^(\[?domain( ?name)?\]?|domain)$`),
+	"registrar":   regexp.MustCompile(`(?i)^(registrar( name)?|sponsoring registrar)$`),
+	"createdDate": regexp.MustCompile(`(?i)^(creation date|created( on)?|registered( on)?|domain registration date|registration time)$`),
+	"updatedDate": regexp.MustCompile(`(?i)^(updated date|last update(d)?( on)?|modified( date)?|changed)$`),
+	"expiresDate": regexp.MustCompile(`(?i)^(registry expiry date|expir(y|ation)( date| time)?|paid-till|valid until)$`),
+	"nameServers": regexp.MustCompile(`(?i)^(n(ame ?)?(server)s?( information)?)$`),
+	"status":      regexp.MustCompile(`(?i)^(domain status|status)$`),
+	"countryCode": regexp.MustCompile(`(?i)^(country( code)?)$`),
+}
+
+/* dateFormats is the list of layouts we try, in order, when parsing
+ * a date-like value.  Keep this in sync with the whois servers'
+ * idiosyncrasies as they're discovered. */
+var dateFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02-Jan-2006",
+	"02-01-2006",
+	"2006/01/02",
+	"2006.01.02",
+	"20060102",
+	"Mon Jan 02 15:04:05 MST 2006",
+	"2006/01/02 15:04:05 (MST)",
+}
+
+var emailRE = regexp.MustCompile(`[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+`)
+
+/* ParseDate tries each known layout in turn and returns the first
+ * successful parse, normalized to UTC. */
+func ParseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(s, " (JST)")
+	s = strings.TrimSuffix(s, " (UTC)")
+	for _, layout := range dateFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+/* CountryName returns the English short name for an ISO-3166
+ * alpha-2 or alpha-3 code, or "" if the code is unknown. */
+func CountryName(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if name, found := countryNames[code]; found {
+		return name
+	}
+	if alpha2, found := countryAlpha3[code]; found {
+		return countryNames[alpha2]
+	}
+	return ""
+}
+
+/* ExtractEmails scans s for RFC-5322-shaped addresses and returns
+ * the deduplicated list, in the order first seen. */
+func ExtractEmails(s string) []string {
+	seen := map[string]bool{}
+	emails := []string{}
+	for _, m := range emailRE.FindAllString(s, -1) {
+		if !seen[m] {
+			seen[m] = true
+			emails = append(emails, m)
+		}
+	}
+	return emails
+}
+
+func canonicalField(key string) string {
+	for field, re := range fieldPatterns {
+		if re.MatchString(strings.TrimSpace(key)) {
+			return field
+		}
+	}
+	return ""
+}
+
+func asStrings(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []interface{}:
+		out := []string{}
+		for _, i := range t {
+			if s, ok := i.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func contactFromMap(m map[string]interface{}) *Contact {
+	c := &Contact{}
+	for k, v := range m {
+		s := strings.Join(asStrings(v), ", ")
+		switch {
+		case regexp.MustCompile(`(?i)name`).MatchString(k):
+			c.Name = s
+		case regexp.MustCompile(`(?i)organi[sz]ation`).MatchString(k):
+			c.Organization = s
+		case regexp.MustCompile(`(?i)e-?mail`).MatchString(k):
+			c.Email = s
+		case regexp.MustCompile(`(?i)phone`).MatchString(k):
+			c.Phone = s
+		case regexp.MustCompile(`(?i)fax`).MatchString(k):
+			c.Fax = s
+		case regexp.MustCompile(`(?i)(street|address)`).MatchString(k):
+			c.Street = s
+		case regexp.MustCompile(`(?i)city`).MatchString(k):
+			c.City = s
+		case regexp.MustCompile(`(?i)(state|province)`).MatchString(k):
+			c.State = s
+		case regexp.MustCompile(`(?i)(postal|zip)`).MatchString(k):
+			c.PostalCode = s
+		case regexp.MustCompile(`(?i)country`).MatchString(k):
+			c.Country = s
+		}
+	}
+	return c
+}
+
+/* Normalize walks the raw key/value tree produced by askWhois (a
+ * map[string]interface{} whose values may be strings, []string, or
+ * nested maps/subobjects) and returns the canonical WhoisRecord.
+ * Unrecognized keys are dropped; this is a best-effort mapping, not
+ * a lossless transform -- the raw tree remains available alongside
+ * it in the tool's JSON output. */
+func Normalize(data map[string]interface{}) *WhoisRecord {
+	r := &WhoisRecord{}
+	raw := []string{}
+
+	for key, value := range data {
+		switch key {
+		case "registrant", "admin", "admin-c", "admin contact":
+			if m, ok := value.(map[string]interface{}); ok {
+				r.Registrant = contactFromMap(m)
+			}
+			continue
+		case "tech", "tech-c", "technical contact":
+			if m, ok := value.(map[string]interface{}); ok {
+				r.Tech = contactFromMap(m)
+			}
+			continue
+		}
+
+		switch field := canonicalField(key); field {
+		case "domainName":
+			if s := asStrings(value); len(s) > 0 {
+				r.DomainName = s[0]
+			}
+		case "registrar":
+			if s := asStrings(value); len(s) > 0 {
+				r.Registrar = s[0]
+			}
+		case "createdDate":
+			if s := asStrings(value); len(s) > 0 {
+				if t, ok := ParseDate(s[0]); ok {
+					r.CreatedDate = &t
+				}
+			}
+		case "updatedDate":
+			if s := asStrings(value); len(s) > 0 {
+				if t, ok := ParseDate(s[0]); ok {
+					r.UpdatedDate = &t
+				}
+			}
+		case "expiresDate":
+			if s := asStrings(value); len(s) > 0 {
+				if t, ok := ParseDate(s[0]); ok {
+					r.ExpiresDate = &t
+				}
+			}
+		case "nameServers":
+			switch t := value.(type) {
+			case []string:
+				r.NameServers = append(r.NameServers, t...)
+			case map[string][]string:
+				for ns := range t {
+					r.NameServers = append(r.NameServers, ns)
+				}
+			}
+		case "status":
+			r.Status = append(r.Status, asStrings(value)...)
+		case "countryCode":
+			if s := asStrings(value); len(s) > 0 {
+				r.CountryCode = strings.ToUpper(s[0])
+				r.CountryName = CountryName(r.CountryCode)
+			}
+		}
+
+		raw = append(raw, asStrings(value)...)
+	}
+
+	r.Emails = ExtractEmails(strings.Join(raw, "\n"))
+
+	return r
+}
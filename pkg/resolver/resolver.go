@@ -0,0 +1,170 @@
+/* Package resolver drives the (potentially branching) WHOIS referral
+ * chase concurrently: a query against, say, an ASN at ARIN may point
+ * to RIPE, which in turn points at several per-org handles.  Rather
+ * than chasing those one at a time, Resolver fans out across a
+ * bounded worker pool, dedupes repeat (server,query) pairs, and
+ * records the edges it followed so callers can hand back a
+ * machine-readable trace of how an answer was assembled.
+ */
+package resolver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/* AskFunc performs a single lookup against server for query and
+ * returns the parsed result; it is the same shape as askWhois. */
+type AskFunc func(ctx context.Context, server, query string) map[string]interface{}
+
+/* Edge is one hop in the referral chase, from the server that named
+ * the referral to the server it pointed at. */
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+/* ReferralGraph is the full set of edges followed while resolving a
+ * single query. */
+type ReferralGraph struct {
+	Edges []Edge `json:"edges"`
+}
+
+/* Resolver fans out WHOIS referral chasing across a bounded pool of
+ * workers.  Create one with New and reuse it across queries if you
+ * want rate limiting to apply across the whole run. */
+type Resolver struct {
+	Parallel int
+	Ask      AskFunc
+
+	/* RatePerServer, if set, is the minimum spacing enforced
+	 * between two requests to the same server. */
+	RatePerServer time.Duration
+
+	mu          sync.Mutex
+	seen        map[string]bool
+	lastRequest map[string]time.Time
+}
+
+/* New returns a Resolver that runs up to parallel lookups at once. */
+func New(parallel int, ask AskFunc) *Resolver {
+	if parallel < 1 {
+		parallel = 1
+	}
+	return &Resolver{
+		Parallel:    parallel,
+		Ask:         ask,
+		seen:        map[string]bool{},
+		lastRequest: map[string]time.Time{},
+	}
+}
+
+func (r *Resolver) throttle(server string) {
+	if r.RatePerServer <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	wait := time.Duration(0)
+	if last, found := r.lastRequest[server]; found {
+		if elapsed := now.Sub(last); elapsed < r.RatePerServer {
+			wait = r.RatePerServer - elapsed
+		}
+	}
+	r.lastRequest[server] = now.Add(wait)
+	r.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+/* claim returns true the first time (server,query) is seen, so a
+ * referral cycle or diamond (two servers both referring to the same
+ * third server) is only ever queried once. */
+func (r *Resolver) claim(server, query string) bool {
+	key := server + "\x00" + query
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.seen[key] {
+		return false
+	}
+	r.seen[key] = true
+	return true
+}
+
+/* referralsOf extracts the servers a response points at next.  It
+ * prefers the "refs" list (all referral hints askWhois noticed) and
+ * falls back to the single "next" field for AskFuncs that only ever
+ * produce one. */
+func referralsOf(data map[string]interface{}) []string {
+	if refs, ok := data["refs"].([]string); ok && len(refs) > 0 {
+		return refs
+	}
+	if next, ok := data["next"].(string); ok && len(next) > 0 {
+		return []string{next}
+	}
+	return nil
+}
+
+/* Resolve fans out from root, following every referral hint found in
+ * each response until no new (server,query) pair remains.  It
+ * returns the per-server results, the order in which servers were
+ * first reached (root first), and the ReferralGraph describing how
+ * they relate. */
+func (r *Resolver) Resolve(ctx context.Context, root, query string) (results map[string]map[string]interface{}, order []string, graph ReferralGraph) {
+	results = map[string]map[string]interface{}{}
+
+	sem := make(chan struct{}, r.Parallel)
+	var wg sync.WaitGroup
+	var resMu sync.Mutex
+
+	var dispatch func(server, parent string)
+	dispatch = func(server, parent string) {
+		if !r.claim(server, query) {
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			/* The semaphore gates only the Ask call, not the
+			 * recursion below: a goroutine that held its slot
+			 * across dispatch() calls for its own referrals
+			 * would block waiting for a child to finish while
+			 * still occupying a slot the child needs to even
+			 * start, and a pool full of such parents deadlocks
+			 * outright. Acquiring/releasing around Ask alone
+			 * means a slot is only ever held by work actually
+			 * in flight. */
+			sem <- struct{}{}
+			r.throttle(server)
+			data := r.Ask(ctx, server, query)
+			<-sem
+
+			resMu.Lock()
+			results[server] = data
+			order = append(order, server)
+			if parent != "" {
+				graph.Edges = append(graph.Edges, Edge{From: parent, To: server})
+			}
+			resMu.Unlock()
+
+			for _, next := range referralsOf(data) {
+				if next != server {
+					dispatch(next, server)
+				}
+			}
+		}()
+	}
+
+	dispatch(root, "")
+	wg.Wait()
+
+	return
+}
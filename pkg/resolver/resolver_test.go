@@ -0,0 +1,81 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+/* TestResolveWideBranchingUnderBoundedPool is the regression test for
+ * the worker-pool deadlock: a referral graph whose branching exceeds
+ * Parallel (here, 2 workers chasing root's 3 referrals, one of which
+ * has its own referral) used to hang forever because a parent held
+ * its pool slot while recursing into its children. Resolve must still
+ * return well within the test timeout. */
+func TestResolveWideBranchingUnderBoundedPool(t *testing.T) {
+	refs := map[string][]string{
+		"root": {"a", "b", "c"},
+		"a":    {"d"},
+	}
+
+	ask := func(ctx context.Context, server, query string) map[string]interface{} {
+		time.Sleep(20 * time.Millisecond)
+		data := map[string]interface{}{}
+		if next, ok := refs[server]; ok {
+			data["refs"] = next
+		}
+		return data
+	}
+
+	r := New(2, ask)
+
+	done := make(chan struct{})
+	var results map[string]map[string]interface{}
+	var order []string
+
+	go func() {
+		results, order, _ = r.Resolve(context.Background(), "root", "query")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Resolve did not return within 5s; the worker pool likely deadlocked")
+	}
+
+	for _, server := range []string{"root", "a", "b", "c", "d"} {
+		if _, found := results[server]; !found {
+			t.Errorf("missing result for %q, got %v", server, order)
+		}
+	}
+}
+
+func TestResolveDedupesDiamondReferral(t *testing.T) {
+	calls := map[string]int{}
+
+	refs := map[string][]string{
+		"root": {"a", "b"},
+		"a":    {"c"},
+		"b":    {"c"},
+	}
+
+	ask := func(ctx context.Context, server, query string) map[string]interface{} {
+		calls[server]++
+		data := map[string]interface{}{}
+		if next, ok := refs[server]; ok {
+			data["refs"] = next
+		}
+		return data
+	}
+
+	r := New(4, ask)
+	results, _, _ := r.Resolve(context.Background(), "root", "query")
+
+	if calls["c"] != 1 {
+		t.Errorf("c was asked %d times, want exactly 1 (root->a->c and root->b->c should dedupe)", calls["c"])
+	}
+	if len(results) != 4 {
+		t.Errorf("got %d results, want 4 (root, a, b, c)", len(results))
+	}
+}
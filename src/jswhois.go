@@ -15,16 +15,25 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/jschauma/jswhois/internal/cache"
+	"github.com/jschauma/jswhois/internal/format"
+	"github.com/jschauma/jswhois/internal/profiles"
+	"github.com/jschauma/jswhois/internal/rdap"
+	"github.com/jschauma/jswhois/internal/schema"
+	"github.com/jschauma/jswhois/pkg/resolver"
 )
 
 const PROGNAME = "jswhois"
@@ -35,12 +44,24 @@ const EXIT_SUCCESS = 0
 
 const IANAWHOIS = "whois.iana.org"
 
+const DEFAULT_CACHE_TTL = time.Hour
+const DEFAULT_TIMEOUT = 10 * time.Second
+
+var CACHE_PATH = ""
+var CACHE_TTL = DEFAULT_CACHE_TTL
+var TIMEOUT = DEFAULT_TIMEOUT
 var DEFAULT_WHOIS = IANAWHOIS
 var PORT = 43
 var FORCE = false
+var JOBS = 1
 var LEAF_ONLY = false
-var OUTPUT = map[string]interface{}{}
+var OUTPUT_FORMAT = format.DefaultFormat
+var PARALLEL = 1
+var PREFER = "whois"
+var RECORD_DIR = ""
 var RECURSIVE = true
+var SERVER_ADDR = ""
+var SCHEMA = false
 var VERBOSITY int
 
 var COMMENTS = map[string]bool{
@@ -164,139 +185,12 @@ var FORMAT_PATTERNS = map[string]*regexp.Regexp{
 
 const DEFAULT_FORMAT = "twoColumnsStrict"
 
-/* "createObject" is like IANA output:
- *
- * key1: val
- * key2: val
- *
- * key3: val
- * key4: val
- *
- * This setting is used at times to force the creation
- * of subobjects that are grouped together.
- */
-var CREATE_OBJECT_LOOKUP = map[string]bool{
-	"whois.afrinic.net":   true,
-	"whois.apnic.net":     true,
-	"whois.dns.be":        true,
-	"whois.dominio.gq":    true,
-	"whois.dot.cf":        true,
-	"whois.dot.ml":        true,
-	"whois.dot.tk":        true,
-	"whois.iana.org":      true,
-	"whois.isnic.is":      true,
-	"whois.isoc.org.il":   true,
-	"whois.lacnic.net":    true,
-	"whois.marnet.mk":     true,
-	"whois.nic.alsace":    true,
-	"whois.nic.aquarelle": true,
-	"whois.nic.ar":        true,
-	"whois.nic.at":        true,
-	"whois.nic.bo":        true,
-	"whois.nic.bostik":    true,
-	"whois.nic.bzh":       true,
-	"whois.nic.corsica":   true,
-	"whois.nic.cr":        true,
-	"whois.nic.cz":        true,
-	"whois.nic.fr":        true,
-	"whois.nic.lancaster": true,
-	"whois.nic.leclerc":   true,
-	"whois.nic.mma":       true,
-	"whois.nic.museum":    true,
-	"whois.nic.mw":        true,
-	"whois.nic.ovh":       true,
-	"whois.nic.paris":     true,
-	"whois.nic.pm":        true,
-	"whois.nic.re":        true,
-	"whois.nic.sm":        true,
-	"whois.nic.sn":        true,
-	"whois.nic.sncf":      true,
-	"whois.nic.tf":        true,
-	"whois.nic.tr":        true,
-	"whois.nic.ve":        true,
-	"whois.nic.wf":        true,
-	"whois.nic.yt":        true,
-	"whois.registro.br":   true,
-	"whois.ripe.net":      true,
-	"whois.rnids.rs":      true,
-	"whois.sk-nic.sk":     true,
-	"whois.tznic.or.tz":   true,
-	"whois.ua":            true,
-}
-
-var FORMAT_LOOKUP = map[string]string{
-	"whois.bnnic.bn":           "simpleSubobjects",
-	"whois.cctld.uz":           "multiline",
-	"whois.dns.be":             "twoColumnSubobjects",
-	"whois.dns.pl":             "twoColumnsAddIfMissing",
-	"whois.domain-registry.nl": "multiline",
-	"whois.dominio.gq":         "twoColumnSubobjects",
-	"whois.dot.cf":             "twoColumnSubobjects",
-	"whois.dot.ml":             "twoColumnSubobjects",
-	"whois.dot.tk":             "twoColumnSubobjects",
-	"whois.educause.edu":       "multiline",
-	"whois.eu":                 "simpleSubobjects",
-	"whois.gg":                 "simpleSubobjects",
-	"whois.je":                 "simpleSubobjects",
-	"whois.jprs.jp":            "twoColumnsBrackets",
-	"whois.kr":                 "twoColumnSubobjects",
-	"whois.kg":                 "simpleSubobjects",
-	"whois.monic.mo":           "multiline",
-	"whois.mx":                 "twoColumnSubobjects",
-	"whois.nic.as":             "simpleSubobjects",
-	"whois.nic.aw":             "simpleSubobjects",
-	"whois.nic.it":             "twoColumnSubobjects",
-	"whois.nic.lv":             "twoColumnSubobjects",
-	"whois.nic.net.sa":         "multiline",
-	"whois.nic.sm":             "twoColumnSubobjects",
-	"whois.nic.tm":             "columnContinue",
-	"whois.nic.tr":             "twoColumnSubobjects",
-	"whois.nic.uk":             "multiline",
-	"whois.register.bg":        "multiline",
-	"whois.sgnic.sg":           "simpleSubobjects",
-	"whois.tld.ee":             "twoColumnSubobjects",
-	"whois.tonic.to":           "multiline",
-	"whois.twnic.net.tw":       "multiline",
-}
-
-/* Some whois servers generate output that continues
- * after common 'end' markers... */
-var IGNOREEND_LOOKUP = map[string]bool{
-	"whois.bnnic.bn":     true,
-	"whois.educause.edu": true,
-	"whois.gg":           true,
-	"whois.minico.mo":    true,
-	"whois.nic.firmdale": true,
-	"whois.nic.gdn":      true,
-	"whois.sgnic.sg":     true,
-}
-
-/* Some whois servers begin (or end) object markers
- * or keys with additional strings. */
-var STRIPSTRINGS_LOOKUP = map[string][]string {
-	"whois.nic.tr": []string{"**"},
-	"whois.nic.lv": []string{"[", "]"},
-}
-
-/* Used to force creation of key-values in a strict
- * two-column format.  This helps when encountering
- * single-column lines that belong to a previous
- * subobject. */
-var TWOCOLUMN_LOOKUP = map[string]bool{
-	"whois.bnnic.bn":   true,
-	"whois.eu":         true,
-	"whois.gg":         true,
-	"whois.je":         true,
-	"whois.kg":         true,
-	"whois.mx":         true,
-	"whois.nic.as":     true,
-	"whois.nic.aw":     true,
-	"whois.nic.it":     true,
-	"whois.nic.lv":     true,
-	"whois.nic.net.sa": true,
-	"whois.nic.sm":     true,
-	"whois.sgnic.sg":   true,
-}
+/* The per-server quirks that used to live here as several parallel
+ * maps (which format a server uses, whether it groups entries into
+ * subobjects, and so on) now live in internal/profiles as a single
+ * ServerProfile per server, seeded from an embedded defaults.json
+ * and extensible at runtime via -profiles, RegisterProfile, or
+ * RegisterFormat. */
 
 var NS_RE = regexp.MustCompile(`(?i)^(n(ame ?)?(server)s?( information)?)|(d(omain|ns)( servers)?)`)
 var KV_RE = regexp.MustCompile(`^([^:]+):\s+(.+)$`)
@@ -436,11 +330,12 @@ func argcheck(flag string, args []string, i int) {
  * thing is really tedious and annoying, which is why
  * we ended up with the mess below.  I apologize.
  */
-func askWhois(server, query string) (data map[string]interface{}) {
+func askWhois(ctx context.Context, server, query string) (data map[string]interface{}) {
 	data = map[string]interface{}{}
 	verbose(2, "Looking up '%s' at '%s'...", query, server)
 
 	nextWhois := ""
+	referralCandidates := []string{}
 	subObject := SubObject{}
 	previousKey := ""
 	thisKey := ""
@@ -485,17 +380,14 @@ func askWhois(server, query string) (data map[string]interface{}) {
 	 * keep track. */
 	indentation := ""
 
-	/* Ok, this is sneaky.  We use a map for O(1)
-	 * lookup; if an entry is found, it uses these
-	 * types of objects or settings. */
-	_, createObject := CREATE_OBJECT_LOOKUP[server]
-	_, twoColumn := TWOCOLUMN_LOOKUP[server]
-	_, ignoreEnd := IGNOREEND_LOOKUP[server]
+	profile, _ := profiles.Lookup(server)
+	createObject := profile.CreateObject
+	twoColumn := profile.TwoColumn
+	ignoreEnd := profile.IgnoreEnd
+	stripStrings := profile.StripStrings
 
-	stripStrings := STRIPSTRINGS_LOOKUP[server]
-
-	format, found := FORMAT_LOOKUP[server]
-	if !found {
+	format := profile.Format
+	if len(format) < 1 {
 		format = DEFAULT_FORMAT
 	}
 
@@ -509,7 +401,7 @@ func askWhois(server, query string) (data map[string]interface{}) {
 		delete(COMMENTS, "*")
 	}
 
-	response := runWhois(server, query)
+	response := runWhoisFunc(ctx, server, query)
 	for _, line := range strings.Split(response, "\n") {
 		columns = 0
 
@@ -601,7 +493,17 @@ func askWhois(server, query string) (data map[string]interface{}) {
 
 		key := ""
 		currentValue := ""
-		p := FORMAT_PATTERNS[format]
+		p, found := FORMAT_PATTERNS[format]
+		if !found {
+			/* A format registered at runtime via
+			 * profiles.RegisterFormat; its lines are
+			 * matched but it otherwise falls back to the
+			 * generic handling below. */
+			p, found = profiles.FormatPattern(format)
+			if !found {
+				p = FORMAT_PATTERNS[DEFAULT_FORMAT]
+			}
+		}
 		m := p.FindStringSubmatch(line)
 		switch format {
 		case "multiline":
@@ -947,6 +849,7 @@ func askWhois(server, query string) (data map[string]interface{}) {
 			}
 			if t != server {
 				nextWhois = t
+				referralCandidates = append(referralCandidates, t)
 			}
 		}
 
@@ -987,6 +890,9 @@ func askWhois(server, query string) (data map[string]interface{}) {
 	if len(nextWhois) > 0 {
 		data["next"] = nextWhois
 	}
+	if refs := dedupeStrings(referralCandidates); len(refs) > 0 {
+		data["refs"] = refs
+	}
 
 	data = cleanupData(data)
 	return
@@ -1088,6 +994,19 @@ func expand(entry string, list []string) (back interface{}) {
 	return
 }
 
+/* dedupeStrings returns list with duplicates removed, preserving the
+ * order items were first seen. */
+func dedupeStrings(list []string) (out []string) {
+	seen := map[string]bool{}
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return
+}
+
 func fail(format string, v ...interface{}) {
 	fmt.Fprintf(os.Stderr, format+"\n", v...)
 	os.Exit(EXIT_FAILURE)
@@ -1111,6 +1030,10 @@ func getopts() {
 		case "-?":
 			usage(os.Stdout)
 			os.Exit(EXIT_SUCCESS)
+		case "-C":
+			eatit = true
+			argcheck("-C", args, i)
+			CACHE_PATH = args[i+1]
 		case "-Q":
 			RECURSIVE = false
 		case "-R":
@@ -1124,8 +1047,22 @@ func getopts() {
 			eatit = true
 			argcheck("-h", args, i)
 			DEFAULT_WHOIS = args[i+1]
+		case "-j":
+			eatit = true
+			argcheck("-j", args, i)
+			JOBS, err = strconv.Atoi(args[i+1])
+			if err != nil || JOBS < 1 {
+				fail("-j needs a positive number.")
+			}
 		case "-l":
 			LEAF_ONLY = true
+		case "-o":
+			eatit = true
+			argcheck("-o", args, i)
+			if _, found := format.Get(args[i+1]); !found {
+				fail("-o must be one of %s.", strings.Join(format.Names(), ", "))
+			}
+			OUTPUT_FORMAT = args[i+1]
 		case "-p":
 			eatit = true
 			argcheck("-p", args, i)
@@ -1133,6 +1070,61 @@ func getopts() {
 			if err != nil {
 				fail("Port must be a number.")
 			}
+		case "-parallel":
+			eatit = true
+			argcheck("-parallel", args, i)
+			PARALLEL, err = strconv.Atoi(args[i+1])
+			if err != nil || PARALLEL < 1 {
+				fail("-parallel needs a positive number.")
+			}
+		case "-profiles":
+			eatit = true
+			argcheck("-profiles", args, i)
+			if err := profiles.Load(args[i+1]); err != nil {
+				fail("Unable to load -profiles %s: %s", args[i+1], err)
+			}
+		case "-r":
+			fallthrough
+		case "-rdap":
+			PREFER = "rdap"
+		case "-record":
+			eatit = true
+			argcheck("-record", args, i)
+			RECORD_DIR = args[i+1]
+		case "-prefer":
+			eatit = true
+			argcheck("-prefer", args, i)
+			PREFER = args[i+1]
+			switch PREFER {
+			case "rdap", "whois", "auto":
+				/* valid */
+			default:
+				fail("-prefer must be one of 'rdap', 'whois', or 'auto'.")
+			}
+		case "-schema":
+			fallthrough
+		case "--normalize":
+			SCHEMA = true
+		case "-server":
+			eatit = true
+			argcheck("-server", args, i)
+			SERVER_ADDR = args[i+1]
+		case "-T":
+			eatit = true
+			argcheck("-T", args, i)
+			secs, terr := strconv.Atoi(args[i+1])
+			if terr != nil || secs < 1 {
+				fail("-T needs a positive number of seconds.")
+			}
+			CACHE_TTL = time.Duration(secs) * time.Second
+		case "-t":
+			eatit = true
+			argcheck("-t", args, i)
+			secs, terr := strconv.Atoi(args[i+1])
+			if terr != nil || secs < 1 {
+				fail("-t needs a positive number of seconds.")
+			}
+			TIMEOUT = time.Duration(secs) * time.Second
 		case "-v":
 			VERBOSITY++
 		default:
@@ -1156,59 +1148,278 @@ func hasMarker(list map[string]bool, line string) (yesno bool) {
 	return
 }
 
+/* lookupWhois runs one lookup per entry in os.Args, using up to JOBS
+ * worker goroutines.  Each job gets its own local output map passed
+ * into oneLookupInto, so concurrent lookups for different queries
+ * don't race; results are written into allOutput by the input's
+ * index, so the answer order in the final JSON array matches the
+ * query order on the command line regardless of which worker
+ * finished first. */
 func lookupWhois() {
-
-	var allOutput = []map[string]interface{}{}
 	verbose(1, "Looking up %d names...", len(os.Args))
 
-	for _, q := range os.Args {
-		OUTPUT = map[string]interface{}{}
-		OUTPUT["query"] = q
-		allOutput = append(allOutput, oneLookup())
-	}
+	allOutput := make([]map[string]interface{}, len(os.Args))
 
-	j, _ := json.Marshal(allOutput)
-	fmt.Printf("%s\n", j)
-}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
 
+	for w := 0; w < JOBS; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				q := os.Args[i]
+				result, err := oneLookupInto(map[string]interface{}{"query": q}, q, RECURSIVE, PREFER)
+				if err != nil {
+					fail("%s", err)
+				}
+				allOutput[i] = result
+			}
+		}()
+	}
 
-func oneLookup() (rval map[string]interface{}) {
-	rval = map[string]interface{}{}
-	query := OUTPUT["query"].(string)
+	for i := range os.Args {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
 
-	verbose(2, "Looking up %s...", query)
+	enc, _ := format.Get(OUTPUT_FORMAT)
+	b, err := enc.Encode(allOutput)
+	if err != nil {
+		fail("Unable to encode output as %s: %s", OUTPUT_FORMAT, err)
+	}
+	fmt.Printf("%s\n", b)
+}
 
-	validateQuery(query)
 
-	var chain = []string{DEFAULT_WHOIS}
-	OUTPUT[DEFAULT_WHOIS] = askWhois(DEFAULT_WHOIS, query)
+/* serialLookup follows the single "next" referral hint one hop at a
+ * time, the way jswhois has always done it, and is what we fall back
+ * to when -parallel isn't in play (or -R wasn't given). */
+func serialLookup(ctx context.Context, out map[string]interface{}, query string, recursive bool) (chain []string, data map[string]interface{}) {
+	chain = []string{DEFAULT_WHOIS}
+	out[DEFAULT_WHOIS] = askWhois(ctx, DEFAULT_WHOIS, query)
 
-	data := OUTPUT[DEFAULT_WHOIS].(map[string]interface{})
-	if RECURSIVE {
+	data = out[DEFAULT_WHOIS].(map[string]interface{})
+	if recursive {
 		for {
 			w, found := data["next"].(string)
 			delete(data, "next")
+			delete(data, "refs")
 			if !found {
 				break
 			}
+			if ctx.Err() != nil {
+				break
+			}
 			chain = append(chain, w)
-			OUTPUT[w] = askWhois(w, query)
-			data = OUTPUT[w].(map[string]interface{})
+			out[w] = askWhois(ctx, w, query)
+			data = out[w].(map[string]interface{})
 		}
 	}
-	delete(OUTPUT[DEFAULT_WHOIS].(map[string]interface{}), "next")
+	delete(out[DEFAULT_WHOIS].(map[string]interface{}), "next")
+	delete(out[DEFAULT_WHOIS].(map[string]interface{}), "refs")
 
-	OUTPUT["chain"] = chain
+	return
+}
+
+/* parallelLookup chases every referral hint a response offers (not
+ * just the first) through a bounded resolver.Resolver, so queries
+ * that fan out -- an ASN lookup referring to RIPE and to several
+ * per-org handles, say -- complete in one invocation instead of one
+ * round-trip per hop.  The resulting ReferralGraph is attached to
+ * out as "_referrals". */
+func parallelLookup(ctx context.Context, out map[string]interface{}, query string) (chain []string, data map[string]interface{}) {
+	res := resolver.New(PARALLEL, askWhois)
+	results, order, graph := res.Resolve(ctx, DEFAULT_WHOIS, query)
+
+	for server, d := range results {
+		delete(d, "next")
+		delete(d, "refs")
+		out[server] = d
+	}
+
+	if len(graph.Edges) > 0 {
+		out["_referrals"] = graph.Edges
+	}
+
+	chain = order
+	data = results[chain[len(chain)-1]]
+
+	return
+}
+
+var rdapClient *rdap.Client
+var rdapClientOnce sync.Once
+
+/* rdapClientInstance lazily builds the shared RDAP client; sync.Once
+ * makes this safe to call from the -j worker pool's goroutines. */
+func rdapClientInstance() *rdap.Client {
+	rdapClientOnce.Do(func() { rdapClient = rdap.NewClient() })
+	return rdapClient
+}
+
+/* askRDAP fetches the RDAP record for query, mirroring askWhois's
+ * (server, query) shape so the two transports read the same way at
+ * the call site.  When server is empty it discovers the authoritative
+ * base URL via the IANA bootstrap registry (like
+ * rdap.Client.Lookup); when server is already a full RDAP URL -- a
+ * "related" referral found in a previous response -- it is fetched
+ * directly, no bootstrap involved.  It returns the parsed object, the
+ * URL it was actually fetched from (for the chain), and the next
+ * related RDAP URL to follow, if the response offered one. */
+func askRDAP(ctx context.Context, server, query string) (data map[string]interface{}, fetchedFrom, next string, err error) {
+	verbose(2, "Looking up %s via RDAP (%s)...", query, orBootstrap(server))
+
+	client := rdapClientInstance()
+	if len(server) > 0 {
+		data, err = client.Fetch(ctx, server)
+		fetchedFrom = server
+	} else {
+		data, fetchedFrom, err = client.Lookup(ctx, query)
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	next = rdapRelatedLink(data)
+	return data, fetchedFrom, next, nil
+}
+
+func orBootstrap(server string) string {
+	if len(server) > 0 {
+		return server
+	}
+	return "bootstrap"
+}
+
+/* rdapRelatedLink pulls the first rel="related" href out of an RDAP
+ * response's "links" array, if any -- the signal that this object
+ * refers us on to another RDAP server for more detail (e.g. a
+ * registry referring to a registrar). */
+func rdapRelatedLink(data map[string]interface{}) string {
+	links, ok := data["links"].([]interface{})
+	if !ok {
+		return ""
+	}
+	for _, l := range links {
+		m, ok := l.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if rel, _ := m["rel"].(string); rel != "related" {
+			continue
+		}
+		if href, ok := m["href"].(string); ok && len(href) > 0 {
+			return href
+		}
+	}
+	return ""
+}
+
+/* queryRDAP resolves and fetches the RDAP record for query via
+ * askRDAP, following "related" referrals the way serialLookup follows
+ * WHOIS's "next" hint, and attaching each hop's object to out keyed
+ * by the URL it came from -- so the "chain" output carries the list
+ * of RDAP base URLs visited just like the WHOIS referral chain. */
+func queryRDAP(ctx context.Context, out map[string]interface{}, query string) (chain []string, data map[string]interface{}, err error) {
+	verbose(2, "Looking up %s via RDAP...", query)
+
+	server := ""
+	for {
+		var fetchedFrom, next string
+		data, fetchedFrom, next, err = askRDAP(ctx, server, query)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		out[fetchedFrom] = data
+		chain = append(chain, fetchedFrom)
+
+		if !RECURSIVE || len(next) < 1 || ctx.Err() != nil {
+			break
+		}
+		server = next
+	}
+
+	return chain, data, nil
+}
+
+/* oneLookupInto runs a single lookup, with everything it reads or
+ * writes about this one query living in out and recursive/prefer
+ * passed in explicitly (rather than read off the RECURSIVE/PREFER
+ * globals), so callers that run several of these concurrently --
+ * lookupWhois's -j worker pool, and serveOneQuery's per-connection
+ * goroutines, which also need per-request overrides of recursive/rdap
+ * -- can give each call its own local state and never touch anything
+ * shared.
+ *
+ * It returns any lookup error to the caller rather than calling
+ * fail(): runServer is long-lived and a bad query from one client
+ * must not os.Exit the whole process, so serveOneQuery turns this
+ * error into an {"error": ...} response line instead. */
+func oneLookupInto(out map[string]interface{}, query string, recursive bool, prefer string) (rval map[string]interface{}, err error) {
+	rval = map[string]interface{}{}
+	out["query"] = query
+
+	verbose(2, "Looking up %s...", query)
+
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+
+	if err = validateQuery(ctx, query); err != nil {
+		return nil, err
+	}
+
+	var chain []string
+	var data map[string]interface{}
+	usedRDAP := false
+
+	switch prefer {
+	case "rdap":
+		if chain, data, err = queryRDAP(ctx, out, query); err != nil {
+			return nil, fmt.Errorf("RDAP lookup for %s failed: %s", query, err)
+		}
+		usedRDAP = true
+	case "auto":
+		var rerr error
+		if chain, data, rerr = queryRDAP(ctx, out, query); rerr != nil {
+			verbose(1, "RDAP lookup failed (%s), falling back to WHOIS...", rerr)
+			if recursive && PARALLEL > 1 {
+				chain, data = parallelLookup(ctx, out, query)
+			} else {
+				chain, data = serialLookup(ctx, out, query, recursive)
+			}
+		} else {
+			usedRDAP = true
+		}
+	default:
+		if recursive && PARALLEL > 1 {
+			chain, data = parallelLookup(ctx, out, query)
+		} else {
+			chain, data = serialLookup(ctx, out, query, recursive)
+		}
+	}
+
+	out["chain"] = chain
 
 	if LEAF_ONLY {
-		rval["query"] = OUTPUT["query"]
+		rval["query"] = out["query"]
 		rval["chain"] = chain
 		rval[chain[len(chain)-1]] = data
 	} else {
-		rval = OUTPUT
+		rval = out
 	}
 
-	return
+	if SCHEMA {
+		if usedRDAP {
+			rval["record"] = schema.NormalizeRDAP(data)
+		} else {
+			rval["record"] = schema.Normalize(data)
+		}
+	}
+
+	return rval, nil
 }
 
 
@@ -1216,28 +1427,99 @@ func printVersion() {
 	fmt.Printf("%v version %v\n", PROGNAME, VERSION)
 }
 
-func runWhois(server, query string) (response string) {
+/* runWhoisFunc indirects the actual network call made from
+ * askWhois, so tests can stub it with canned fixture data without
+ * touching the network; see TestParseFixtures in jswhois_test.go. */
+var runWhoisFunc = runWhois
+
+var whoisCache *cache.Cache
+var whoisCacheOnce sync.Once
+
+/* whoisCacheInstance lazily builds the shared response cache, loading
+ * it from CACHE_PATH if -C was given; sync.Once makes this safe to
+ * call from the -j worker pool's goroutines. */
+func whoisCacheInstance() *cache.Cache {
+	whoisCacheOnce.Do(func() {
+		if len(CACHE_PATH) > 0 {
+			whoisCache = cache.Load(CACHE_PATH, CACHE_TTL)
+		} else {
+			whoisCache = cache.New(CACHE_TTL)
+		}
+	})
+	return whoisCache
+}
+
+func runWhois(ctx context.Context, server, query string) (response string) {
+	if cached, negative, found := whoisCacheInstance().Get(server, query); found {
+		verbose(3, "Cache hit for %s/%s", server, query)
+		if !negative {
+			response = cached
+		}
+		return
+	}
+
 	verbose(3, "Asking %s for '%s'...", server, query)
 
-	server += fmt.Sprintf(":%d", PORT)
-	conn, err := net.Dial("tcp", server)
+	rateLimiterFor(server).wait(ctx)
+
+	dialServer := server + fmt.Sprintf(":%d", PORT)
+	dialer := net.Dialer{Timeout: TIMEOUT}
+	conn, err := dialer.DialContext(ctx, "tcp", dialServer)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unable to connect to %s: %s\n", server, err)
+		fmt.Fprintf(os.Stderr, "Unable to connect to %s: %s\n", dialServer, err)
+		whoisCacheInstance().Set(server, query, "")
 		return
 	}
 	defer conn.Close()
 
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+	} else {
+		conn.SetReadDeadline(time.Now().Add(TIMEOUT))
+	}
+
 	/* Why, yes, WHOIS is indeed the world's most
 	 * simple protocol. See RFC3912. */
 	fmt.Fprintf(conn, "%s\r\n", query)
 	s := bufio.NewScanner(conn)
 	for s.Scan() {
+		if ctx.Err() != nil {
+			break
+		}
 		response += s.Text() + "\n"
 	}
 
+	whoisCacheInstance().Set(server, query, response)
+
+	if len(RECORD_DIR) > 0 {
+		recordFixture(server, query, response)
+	}
+
 	return
 }
 
+/* recordFixture saves a raw response next to any live query when
+ * -record is given, so contributors can grow the fixture corpus
+ * simply by running the tool. */
+func recordFixture(server, query, response string) {
+	dir := filepath.Join(RECORD_DIR, server)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		verbose(1, "Unable to record fixture for %s/%s: %s", server, query, err)
+		return
+	}
+
+	path := filepath.Join(dir, sanitizeFixtureName(query)+".txt")
+	if err := os.WriteFile(path, []byte(response), 0644); err != nil {
+		verbose(1, "Unable to record fixture for %s/%s: %s", server, query, err)
+	}
+}
+
+/* sanitizeFixtureName turns a query into a safe filename stem. */
+func sanitizeFixtureName(query string) string {
+	re := regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+	return re.ReplaceAllString(query, "_")
+}
+
 func updateTopOrSubobject(thing interface{}, k, v string) interface{} {
 
 	switch thing.(type) {
@@ -1269,33 +1551,47 @@ func updateTopOrSubobject(thing interface{}, k, v string) interface{} {
 }
 
 func usage(out io.Writer) {
-	usage := `Usage: %v [-?QRVflpv] [-h server] [-p port]
-	-?         print this help and exit
-	-Q         quick lookup (i.e., do not recurse)
-	-R         recursive lookup (default)
-	-V         print version information and exit
-        -f         force lookups
-	-h server  query this server (default: %s)
-        -l         only print output for the last / leaf whois server
-        -p port    query the whois server on this port (default: %d)
-	-v         be verbose
+	usage := `Usage: %v [-?QRVflv] [-h server] [-p port] [-schema]
+	-?             print this help and exit
+        -C path        cache responses on disk at path, loading it back on startup
+	-Q             quick lookup (i.e., do not recurse)
+	-R             recursive lookup (default)
+	-V             print version information and exit
+        -f             force lookups
+	-h server      query this server (default: %s)
+        -j N           look up up to N queries concurrently (default: 1)
+        -l             only print output for the last / leaf whois server
+        -o format      output format: json (default), text, yaml, or jcard
+        -p port        query the whois server on this port (default: %d)
+        -parallel N    chase up to N referrals concurrently (default: 1)
+        -profiles path load/override server profiles from a JSON file or directory
+        -prefer which  which transport to use: 'rdap', 'whois', or 'auto' (default: whois)
+        -r             shorthand for '-prefer rdap'
+        -rdap          shorthand for '-prefer rdap'
+        -record dir    save each live query's raw response under dir/server/query.txt
+        -schema        also emit a normalized 'record' (alias: --normalize)
+        -server addr   serve NDJSON lookups on addr ("host:port" or "unix:path")
+        -T seconds     cache entry TTL in seconds (default: %d)
+        -t seconds     per-query network timeout in seconds (default: %d)
+	-v             be verbose
 `
-	fmt.Fprintf(out, usage, PROGNAME, IANAWHOIS, PORT)
+	fmt.Fprintf(out, usage, PROGNAME, IANAWHOIS, PORT, int(DEFAULT_CACHE_TTL.Seconds()), int(DEFAULT_TIMEOUT.Seconds()))
 }
 
-func validateQuery(query string) {
+func validateQuery(ctx context.Context, query string) error {
 	if FORCE {
-		return
+		return nil
 	}
 
 	verbose(3, "Validating %s...", query)
 	if ip := net.ParseIP(query); ip != nil {
-		return
+		return nil
 	}
 
-	if _, err := net.LookupHost(query); err != nil {
-		fail("%s does not resolve; use '-f' to proceed anyway\n", query)
+	if _, err := net.DefaultResolver.LookupHost(ctx, query); err != nil {
+		return fmt.Errorf("%s does not resolve; use '-f' to proceed anyway", query)
 	}
+	return nil
 }
 
 func verbose(level int, format string, v ...interface{}) {
@@ -1314,5 +1610,12 @@ func verbose(level int, format string, v ...interface{}) {
 
 func main() {
 	getopts()
+	if len(SERVER_ADDR) > 0 {
+		runServer(SERVER_ADDR)
+		return
+	}
 	lookupWhois()
+	if err := whoisCacheInstance().Flush(); err != nil {
+		verbose(1, "Unable to flush cache to %s: %s", CACHE_PATH, err)
+	}
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/* TestParseFixtures runs askWhois() against recorded raw responses
+ * under testdata/fixtures/<server>/<query>.txt (one per whois server
+ * format we support) and compares the result to the matching
+ * <query>.json, so format-parsing regressions are caught without a
+ * live network.  Fixtures are recorded with -record; see
+ * recordFixture() in jswhois.go. */
+func TestParseFixtures(t *testing.T) {
+	root := "../testdata/fixtures"
+
+	servers, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, server := range servers {
+		if !server.IsDir() {
+			continue
+		}
+		serverName := server.Name()
+
+		fixtures, err := os.ReadDir(filepath.Join(root, serverName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, fixture := range fixtures {
+			if fixture.IsDir() || !strings.HasSuffix(fixture.Name(), ".txt") {
+				continue
+			}
+			query := strings.TrimSuffix(fixture.Name(), ".txt")
+
+			t.Run(serverName+"/"+query, func(t *testing.T) {
+				raw, err := os.ReadFile(filepath.Join(root, serverName, query+".txt"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected, err := os.ReadFile(filepath.Join(root, serverName, query+".json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				saved := runWhoisFunc
+				defer func() { runWhoisFunc = saved }()
+				runWhoisFunc = func(ctx context.Context, server, query string) string { return string(raw) }
+
+				got := askWhois(context.Background(), serverName, query)
+
+				var want map[string]interface{}
+				if err := json.Unmarshal(expected, &want); err != nil {
+					t.Fatalf("invalid fixture JSON %s: %s", query+".json", err)
+				}
+
+				gotJSON, err := json.Marshal(got)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantJSON, err := json.Marshal(want)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(gotJSON) != string(wantJSON) {
+					t.Errorf("askWhois(%q, %q) mismatch\n got: %s\nwant: %s", serverName, query, gotJSON, wantJSON)
+				}
+			})
+		}
+	}
+}
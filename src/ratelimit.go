@@ -0,0 +1,89 @@
+/* Per-server rate limiting for runWhois: with -j N dispatching several
+ * lookups at once, repeated queries against the same whois server
+ * (e.g. whois.verisign-grs.com during a -R referral chain, or several
+ * -j workers landing on the same registry) are easy to throttle or
+ * get blocked by.  We hand-roll a small token bucket per server
+ * rather than pull in a rate-limiting package, since this tree has no
+ * other third-party dependencies.
+ */
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/* DEFAULT_SERVER_RATE and DEFAULT_SERVER_BURST bound how often we'll
+ * dial any one whois server: on average one query per quarter second,
+ * with a small burst allowance for the common case of a handful of
+ * queries against the same server in a row. */
+const DEFAULT_SERVER_RATE = 4.0
+const DEFAULT_SERVER_BURST = 4.0
+
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+func newTokenBucket(rate, capacity float64) *tokenBucket {
+	return &tokenBucket{tokens: capacity, capacity: capacity, rate: rate, last: time.Now()}
+}
+
+/* wait blocks until a token is available, refilling at b.rate tokens
+ * per second since it was last visited, or until ctx is done --
+ * otherwise a busy server's limiter could sit a caller out past its
+ * -t TIMEOUT even though everything downstream of wait respects
+ * ctx. Returning early on ctx.Done() without a token just means the
+ * caller proceeds straight into its own ctx-bound dial/read, which
+ * will fail fast on the same deadline. */
+func (b *tokenBucket) wait(ctx context.Context) {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return
+		}
+
+		sleep := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+var serverLimiters = struct {
+	mu sync.Mutex
+	m  map[string]*tokenBucket
+}{m: map[string]*tokenBucket{}}
+
+/* rateLimiterFor returns the shared token bucket for server,
+ * creating it on first use. */
+func rateLimiterFor(server string) *tokenBucket {
+	serverLimiters.mu.Lock()
+	defer serverLimiters.mu.Unlock()
+
+	b, found := serverLimiters.m[server]
+	if !found {
+		b = newTokenBucket(DEFAULT_SERVER_RATE, DEFAULT_SERVER_BURST)
+		serverLimiters.m[server] = b
+	}
+	return b
+}
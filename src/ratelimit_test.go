@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+/* TestTokenBucketBurst checks that a fresh bucket allows up to its
+ * capacity worth of immediate, non-blocking calls. */
+func TestTokenBucketBurst(t *testing.T) {
+	b := newTokenBucket(4.0, 4.0)
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		b.wait(context.Background())
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("burst of 4 against a capacity-4 bucket took %s, expected near-instant", elapsed)
+	}
+}
+
+/* TestTokenBucketThrottles checks that once the burst allowance is
+ * spent, a bucket makes the caller wait roughly 1/rate seconds for
+ * the next token rather than letting it through immediately. */
+func TestTokenBucketThrottles(t *testing.T) {
+	b := newTokenBucket(10.0, 1.0)
+
+	b.wait(context.Background()) // spend the only token
+
+	start := time.Now()
+	b.wait(context.Background())
+	elapsed := time.Since(start)
+
+	want := 100 * time.Millisecond // 1/10.0s
+	if elapsed < want/2 {
+		t.Errorf("second wait() returned after %s, expected to block close to %s", elapsed, want)
+	}
+}
+
+/* TestTokenBucketWaitRespectsContextCancellation checks that wait
+ * gives up as soon as ctx is done rather than sleeping out the full
+ * refill duration, so a limiter on a busy server can't hold a caller
+ * past its -t TIMEOUT. */
+func TestTokenBucketWaitRespectsContextCancellation(t *testing.T) {
+	b := newTokenBucket(1.0, 1.0)
+	b.wait(context.Background()) // spend the only token; next wait would block ~1s
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	b.wait(ctx)
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("wait(ctx) returned after %s, expected to bail out near the 20ms context deadline", elapsed)
+	}
+}
+
+func TestRateLimiterForIsSharedPerServer(t *testing.T) {
+	a := rateLimiterFor("whois.test-ratelimiterfor.example")
+	b := rateLimiterFor("whois.test-ratelimiterfor.example")
+
+	if a != b {
+		t.Error("expected rateLimiterFor to return the same bucket for repeat calls with the same server")
+	}
+
+	other := rateLimiterFor("whois.other-test-ratelimiterfor.example")
+	if a == other {
+		t.Error("expected rateLimiterFor to return distinct buckets for distinct servers")
+	}
+}
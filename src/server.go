@@ -0,0 +1,172 @@
+/* NDJSON server mode: instead of one process per query, -server
+ * turns jswhois into a long-lived service that accepts one query per
+ * line on a TCP or Unix socket connection and emits one JSON
+ * response object per line, so batch pipelines and dashboards don't
+ * pay process-startup cost per lookup.
+ */
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* DEFAULT_SERVER_INFLIGHT bounds how many lookups run at once across
+ * all connections, absent -parallel. */
+const DEFAULT_SERVER_INFLIGHT = 32
+
+/* SERVER_CACHE_TTL is how long a response is served from cache
+ * before the server will look it up again. */
+var SERVER_CACHE_TTL = 60 * time.Second
+
+/* serverRequest is one line of server input: {"query":"example.com",
+ * "recursive":true,"rdap":false}.  Recursive/RDAP are pointers so
+ * "unset" (use the server's defaults) is distinguishable from
+ * "false". */
+type serverRequest struct {
+	Query     string `json:"query"`
+	Recursive *bool  `json:"recursive,omitempty"`
+	RDAP      *bool  `json:"rdap,omitempty"`
+}
+
+type serverCacheEntry struct {
+	response json.RawMessage
+	expires  time.Time
+}
+
+var serverCache = struct {
+	mu      sync.Mutex
+	entries map[string]serverCacheEntry
+}{entries: map[string]serverCacheEntry{}}
+
+func serverCacheGet(key string) (json.RawMessage, bool) {
+	serverCache.mu.Lock()
+	defer serverCache.mu.Unlock()
+
+	e, found := serverCache.entries[key]
+	if !found || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.response, true
+}
+
+func serverCacheSet(key string, response json.RawMessage) {
+	serverCache.mu.Lock()
+	defer serverCache.mu.Unlock()
+	serverCache.entries[key] = serverCacheEntry{response: response, expires: time.Now().Add(SERVER_CACHE_TTL)}
+}
+
+func serverInflight() int {
+	if PARALLEL > 1 {
+		return PARALLEL
+	}
+	return DEFAULT_SERVER_INFLIGHT
+}
+
+/* runServer listens on addr -- "host:port" for TCP, or
+ * "unix:/path/to.sock" for a Unix socket -- and serves NDJSON: one
+ * query object per input line, one JSON response object per output
+ * line, per connection. */
+func runServer(addr string) {
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix:") {
+		network = "unix"
+		addr = strings.TrimPrefix(addr, "unix:")
+	}
+
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		fail("Unable to listen on %s: %s", addr, err)
+	}
+	defer ln.Close()
+
+	verbose(1, "Serving NDJSON lookups on %s (%s)...", addr, network)
+
+	sem := make(chan struct{}, serverInflight())
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			verbose(1, "Accept error: %s", err)
+			continue
+		}
+		go handleServerConn(conn, sem)
+	}
+}
+
+func handleServerConn(conn net.Conn, sem chan struct{}) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) < 1 {
+			continue
+		}
+
+		sem <- struct{}{}
+		resp := serveOneQuery(line)
+		<-sem
+
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+/* serveOneQuery handles a single NDJSON input line: validate it,
+ * serve it from the shared TTL cache if we've seen it recently,
+ * otherwise run it through oneLookupInto with its own local output
+ * map and cache the result. Unlike oneLookup, oneLookupInto touches
+ * no shared state, so concurrent connections' lookups actually run
+ * their network I/O in parallel, up to serverInflight(), instead of
+ * queueing behind one another.
+ * A lookup error (bad domain, RDAP failure, ...) is reported back as
+ * an {"error": ...} line rather than killing the server -- see
+ * oneLookupInto's doc comment. */
+func serveOneQuery(line string) interface{} {
+	var req serverRequest
+	if err := json.Unmarshal([]byte(line), &req); err != nil {
+		return map[string]string{"error": fmt.Sprintf("invalid request: %s", err)}
+	}
+	if len(req.Query) < 1 {
+		return map[string]string{"error": "missing 'query'"}
+	}
+
+	if cached, found := serverCacheGet(line); found {
+		var v interface{}
+		if err := json.Unmarshal(cached, &v); err == nil {
+			return v
+		}
+	}
+
+	recursive := RECURSIVE
+	if req.Recursive != nil {
+		recursive = *req.Recursive
+	}
+	prefer := PREFER
+	if req.RDAP != nil {
+		prefer = "whois"
+		if *req.RDAP {
+			prefer = "rdap"
+		}
+	}
+
+	result, lookupErr := oneLookupInto(map[string]interface{}{"query": req.Query}, req.Query, recursive, prefer)
+	if lookupErr != nil {
+		return map[string]string{"error": lookupErr.Error()}
+	}
+
+	if encoded, err := json.Marshal(result); err == nil {
+		serverCacheSet(line, encoded)
+	}
+
+	return result
+}
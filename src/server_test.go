@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServeOneQueryInvalidJSON(t *testing.T) {
+	resp := serveOneQuery("not json")
+
+	m, ok := resp.(map[string]string)
+	if !ok {
+		t.Fatalf("got %T, want map[string]string", resp)
+	}
+	if _, found := m["error"]; !found {
+		t.Errorf("got %v, want an \"error\" key for invalid JSON input", m)
+	}
+}
+
+func TestServeOneQueryMissingQuery(t *testing.T) {
+	resp := serveOneQuery(`{"recursive":true}`)
+
+	m, ok := resp.(map[string]string)
+	if !ok {
+		t.Fatalf("got %T, want map[string]string", resp)
+	}
+	if _, found := m["error"]; !found {
+		t.Errorf("got %v, want an \"error\" key when 'query' is missing", m)
+	}
+}
+
+/* TestServeOneQueryLookupFailureReportsError is the regression test
+ * for the NDJSON server crash: a query whose lookup fails (here, one
+ * that can't pass validateQuery) must come back as an {"error": ...}
+ * response, not call fail() and take the whole process down with it. */
+func TestServeOneQueryLookupFailureReportsError(t *testing.T) {
+	savedForce, savedTimeout := FORCE, TIMEOUT
+	defer func() { FORCE, TIMEOUT = savedForce, savedTimeout }()
+	FORCE = false
+	TIMEOUT = 2 * time.Second
+
+	resp := serveOneQuery(`{"query":"this-does-not-resolve.invalid"}`)
+
+	m, ok := resp.(map[string]string)
+	if !ok {
+		t.Fatalf("got %T, want map[string]string (the process should still be alive to report this)", resp)
+	}
+	if _, found := m["error"]; !found {
+		t.Errorf("got %v, want an \"error\" key for an unresolvable query", m)
+	}
+}
+
+func TestServerCacheGetSetRoundTrip(t *testing.T) {
+	serverCacheSet("test-key", []byte(`{"query":"example.com"}`))
+
+	got, found := serverCacheGet("test-key")
+	if !found {
+		t.Fatal("expected a cache hit after serverCacheSet")
+	}
+	if string(got) != `{"query":"example.com"}` {
+		t.Errorf("got %s, want the exact bytes stored", got)
+	}
+}
+
+func TestServerCacheExpiry(t *testing.T) {
+	savedTTL := SERVER_CACHE_TTL
+	defer func() { SERVER_CACHE_TTL = savedTTL }()
+	SERVER_CACHE_TTL = time.Millisecond
+
+	serverCacheSet("test-expiry-key", []byte(`{}`))
+	time.Sleep(5 * time.Millisecond)
+
+	if _, found := serverCacheGet("test-expiry-key"); found {
+		t.Error("expected the entry to have expired")
+	}
+}
+
+func TestServerInflightDefaultsToConstant(t *testing.T) {
+	savedParallel := PARALLEL
+	defer func() { PARALLEL = savedParallel }()
+
+	PARALLEL = 1
+	if got := serverInflight(); got != DEFAULT_SERVER_INFLIGHT {
+		t.Errorf("serverInflight() = %d, want the default %d when PARALLEL <= 1", got, DEFAULT_SERVER_INFLIGHT)
+	}
+
+	PARALLEL = 8
+	if got := serverInflight(); got != 8 {
+		t.Errorf("serverInflight() = %d, want PARALLEL (%d) when it's set above 1", got, 8)
+	}
+}